@@ -0,0 +1,248 @@
+package ascv
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+)
+
+// Valori noti per Header.Charset e Frame.Charset.
+const (
+	CharsetASCII     uint8 = 0
+	CharsetCP437     uint8 = 1
+	CharsetUTF8      uint8 = 2
+	CharsetANSIColor uint8 = 3
+)
+
+// Cell rappresenta una singola cella di terminale: un glifo più, per i
+// charset che li supportano, colore di primo piano, di sfondo e attributi
+// (grassetto, sottolineato, ...).
+type Cell struct {
+	Rune rune
+	Fg   uint8
+	Bg   uint8
+	Attr uint8
+}
+
+// Charset converte le celle da/verso un record a dimensione fissa, usato
+// come rappresentazione su disco di Frame.Content.
+type Charset interface {
+	ID() uint8
+	// CellSize è la dimensione in byte di un singolo record di cella.
+	CellSize() int
+	EncodeCell(c Cell) []byte
+	DecodeCell(data []byte) (Cell, error)
+}
+
+var (
+	charsetsMu sync.RWMutex
+	charsets   = map[uint8]Charset{}
+)
+
+// RegisterCharset registra un Charset per il relativo id, sovrascrivendo un
+// eventuale charset già registrato per lo stesso id.
+func RegisterCharset(id uint8, cs Charset) {
+	charsetsMu.Lock()
+	defer charsetsMu.Unlock()
+	charsets[id] = cs
+}
+
+func charsetFor(id uint8) (Charset, bool) {
+	charsetsMu.RLock()
+	defer charsetsMu.RUnlock()
+	cs, ok := charsets[id]
+	return cs, ok
+}
+
+func init() {
+	RegisterCharset(CharsetASCII, asciiCharset{})
+	RegisterCharset(CharsetCP437, cp437Charset{})
+	RegisterCharset(CharsetUTF8, utf8Charset{})
+	RegisterCharset(CharsetANSIColor, ansiColorCharset{})
+}
+
+// asciiCharset codifica ogni cella in un singolo byte ASCII (0-127); fg, bg
+// e attr non sono rappresentati.
+type asciiCharset struct{}
+
+func (asciiCharset) ID() uint8     { return CharsetASCII }
+func (asciiCharset) CellSize() int { return 1 }
+
+func (asciiCharset) EncodeCell(c Cell) []byte {
+	if c.Rune > 127 {
+		return []byte{'?'}
+	}
+	return []byte{byte(c.Rune)}
+}
+
+func (asciiCharset) DecodeCell(data []byte) (Cell, error) {
+	return Cell{Rune: rune(data[0])}, nil
+}
+
+// cp437High sono i rune del semigruppo alto (byte 128-255) di code page 437,
+// nell'ordine in cui compaiono sulla pagina: accenti, simboli e i caratteri
+// di disegno a blocchi e a linee usati dall'ASCII art in stile DOS. I byte
+// 0-127 coincidono con ASCII e non hanno bisogno di tabella.
+var cp437High = [128]rune{
+	'Ç', 'ü', 'é', 'â', 'ä', 'à', 'å', 'ç', 'ê', 'ë', 'è', 'ï', 'î', 'ì', 'Ä', 'Å',
+	'É', 'æ', 'Æ', 'ô', 'ö', 'ò', 'û', 'ù', 'ÿ', 'Ö', 'Ü', '¢', '£', '¥', '₧', 'ƒ',
+	'á', 'í', 'ó', 'ú', 'ñ', 'Ñ', 'ª', 'º', '¿', '⌐', '¬', '½', '¼', '¡', '«', '»',
+	'░', '▒', '▓', '│', '┤', '╡', '╢', '╖', '╕', '╣', '║', '╗', '╝', '╜', '╛', '┐',
+	'└', '┴', '┬', '├', '─', '┼', '╞', '╟', '╚', '╔', '╩', '╦', '╠', '═', '╬', '╧',
+	'╨', '╤', '╥', '╙', '╘', '╒', '╓', '╫', '╪', '┘', '┌', '█', '▄', '▌', '▐', '▀',
+	'α', 'ß', 'Γ', 'π', 'Σ', 'σ', 'µ', 'τ', 'Φ', 'Θ', 'Ω', 'δ', '∞', 'φ', 'ε', '∩',
+	'≡', '±', '≥', '≤', '⌠', '⌡', '÷', '≈', '°', '∙', '·', '√', 'ⁿ', '²', '■', ' ',
+}
+
+// cp437Reverse mappa i rune del semigruppo alto al loro byte CP437, costruita
+// da cp437High all'inizializzazione del package.
+var cp437Reverse = func() map[rune]byte {
+	m := make(map[rune]byte, len(cp437High))
+	for i, r := range cp437High {
+		m[r] = byte(128 + i)
+	}
+	return m
+}()
+
+// cp437Charset codifica ogni cella in un singolo byte di code page 437,
+// inclusi gli accenti e i caratteri di disegno a blocchi e a linee del
+// semigruppo alto (byte 128-255), secondo cp437High.
+type cp437Charset struct{}
+
+func (cp437Charset) ID() uint8     { return CharsetCP437 }
+func (cp437Charset) CellSize() int { return 1 }
+
+func (cp437Charset) EncodeCell(c Cell) []byte {
+	if c.Rune <= 127 {
+		return []byte{byte(c.Rune)}
+	}
+	if b, ok := cp437Reverse[c.Rune]; ok {
+		return []byte{b}
+	}
+	return []byte{'?'}
+}
+
+func (cp437Charset) DecodeCell(data []byte) (Cell, error) {
+	b := data[0]
+	if b <= 127 {
+		return Cell{Rune: rune(b)}, nil
+	}
+	return Cell{Rune: cp437High[b-128]}, nil
+}
+
+// utf8Charset codifica ogni cella come rune UTF-8 in un record a lunghezza
+// fissa di utf8.UTFMax byte, con padding a zero.
+type utf8Charset struct{}
+
+func (utf8Charset) ID() uint8     { return CharsetUTF8 }
+func (utf8Charset) CellSize() int { return utf8.UTFMax }
+
+func (utf8Charset) EncodeCell(c Cell) []byte {
+	buf := make([]byte, utf8.UTFMax)
+	utf8.EncodeRune(buf, c.Rune)
+	return buf
+}
+
+func (utf8Charset) DecodeCell(data []byte) (Cell, error) {
+	r, _ := utf8.DecodeRune(data)
+	return Cell{Rune: r}, nil
+}
+
+// ansiColorCharset estende utf8Charset con un byte di colore di primo
+// piano, uno di sfondo e uno di attributi, per l'ANSI art colorata.
+type ansiColorCharset struct{}
+
+func (ansiColorCharset) ID() uint8     { return CharsetANSIColor }
+func (ansiColorCharset) CellSize() int { return utf8.UTFMax + 3 }
+
+func (ansiColorCharset) EncodeCell(c Cell) []byte {
+	buf := make([]byte, utf8.UTFMax+3)
+	utf8.EncodeRune(buf[:utf8.UTFMax], c.Rune)
+	buf[utf8.UTFMax] = c.Fg
+	buf[utf8.UTFMax+1] = c.Bg
+	buf[utf8.UTFMax+2] = c.Attr
+	return buf
+}
+
+func (ansiColorCharset) DecodeCell(data []byte) (Cell, error) {
+	r, _ := utf8.DecodeRune(data[:utf8.UTFMax])
+	return Cell{Rune: r, Fg: data[utf8.UTFMax], Bg: data[utf8.UTFMax+1], Attr: data[utf8.UTFMax+2]}, nil
+}
+
+// Cells decodifica Content in base a Charset, restituendo un errore se la
+// sua lunghezza non è un multiplo della dimensione di cella.
+func (f Frame) Cells() ([]Cell, error) {
+	cs, ok := charsetFor(f.Charset)
+	if !ok {
+		return nil, fmt.Errorf("ascv: unknown charset %d", f.Charset)
+	}
+
+	size := cs.CellSize()
+	if len(f.Content)%size != 0 {
+		return nil, fmt.Errorf("ascv: frame content length %d is not a multiple of cell size %d", len(f.Content), size)
+	}
+
+	cells := make([]Cell, len(f.Content)/size)
+	for i := range cells {
+		cell, err := cs.DecodeCell(f.Content[i*size : (i+1)*size])
+		if err != nil {
+			return nil, err
+		}
+		cells[i] = cell
+	}
+	return cells, nil
+}
+
+// NewFrameFromCells codifica cells secondo cs e restituisce il Frame
+// risultante, pronto per essere scritto con Encoder.WriteFrame.
+func NewFrameFromCells(cs Charset, cells []Cell) Frame {
+	var buffer bytes.Buffer
+	for _, cell := range cells {
+		buffer.Write(cs.EncodeCell(cell))
+	}
+	content := buffer.Bytes()
+	return Frame{Size: len(content), Content: content, Charset: cs.ID()}
+}
+
+// EncodeCellRLE comprime una sequenza di celle a dimensione fissa (come
+// prodotta da NewFrameFromCells) eseguendo il run-length encoding su interi
+// record di cellSize byte invece che sui singoli byte. È necessario per i
+// charset colorati: EncodeRLE, operando byte a byte, spezzerebbe un record
+// di cella a metà e confonderebbe glifi uguali con colore diverso per
+// glifi ripetuti, collassandoli in modo scorretto.
+func EncodeCellRLE(cellSize int, data []byte) []byte {
+	var buffer bytes.Buffer
+	n := len(data) / cellSize
+	for i := 0; i < n; {
+		count := 1
+		for i+count < n && count < 255 && bytes.Equal(cellAt(data, cellSize, i), cellAt(data, cellSize, i+count)) {
+			count++
+		}
+		buffer.WriteByte(byte(count))
+		buffer.Write(cellAt(data, cellSize, i))
+		i += count
+	}
+	return buffer.Bytes()
+}
+
+// DecodeCellRLE decomprime dati prodotti da EncodeCellRLE.
+func DecodeCellRLE(cellSize int, data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	recordSize := 1 + cellSize
+	if len(data)%recordSize != 0 {
+		return nil, fmt.Errorf("ascv: invalid cell RLE data for cell size %d", cellSize)
+	}
+	for i := 0; i < len(data); i += recordSize {
+		count := int(data[i])
+		cell := data[i+1 : i+1+cellSize]
+		for j := 0; j < count; j++ {
+			buffer.Write(cell)
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+func cellAt(data []byte, cellSize, i int) []byte {
+	return data[i*cellSize : (i+1)*cellSize]
+}
@@ -0,0 +1,108 @@
+package ascv
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCharsetCellsRoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		cs   Charset
+		cell Cell
+	}{
+		{"ascii", asciiCharset{}, Cell{Rune: 'Q'}},
+		{"cp437 ascii range", cp437Charset{}, Cell{Rune: 'Q'}},
+		{"cp437 high range", cp437Charset{}, Cell{Rune: '█'}},
+		{"utf8", utf8Charset{}, Cell{Rune: '本'}},
+		{"ansi color", ansiColorCharset{}, Cell{Rune: '@', Fg: 1, Bg: 2, Attr: 4}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			frame := NewFrameFromCells(c.cs, []Cell{c.cell})
+			if frame.Charset != c.cs.ID() {
+				t.Fatalf("frame.Charset = %d, want %d", frame.Charset, c.cs.ID())
+			}
+			cells, err := frame.Cells()
+			if err != nil {
+				t.Fatalf("Cells: %v", err)
+			}
+			if len(cells) != 1 || cells[0] != c.cell {
+				t.Errorf("Cells = %+v, want [%+v]", cells, c.cell)
+			}
+		})
+	}
+}
+
+func TestCellsRejectsMisalignedContent(t *testing.T) {
+	frame := Frame{Charset: CharsetANSIColor, Content: []byte{1, 2, 3}} // non multiplo della cella
+	if _, err := frame.Cells(); err == nil {
+		t.Fatal("Cells: want error for misaligned content, got nil")
+	}
+}
+
+func TestCellAwareRLERoundTrip(t *testing.T) {
+	cells := []Cell{
+		{Rune: 'a', Fg: 1}, {Rune: 'a', Fg: 1}, {Rune: 'a', Fg: 1},
+		{Rune: 'a', Fg: 2}, // stesso glifo, colore diverso: non deve collassare col run precedente
+		{Rune: 'b', Fg: 2}, {Rune: 'b', Fg: 2},
+	}
+	frame := NewFrameFromCells(ansiColorCharset{}, cells)
+	cellSize := ansiColorCharset{}.CellSize()
+
+	encoded := EncodeCellRLE(cellSize, frame.Content)
+	decoded, err := DecodeCellRLE(cellSize, encoded)
+	if err != nil {
+		t.Fatalf("DecodeCellRLE: %v", err)
+	}
+	if !bytes.Equal(decoded, frame.Content) {
+		t.Fatalf("DecodeCellRLE round trip mismatch")
+	}
+}
+
+// TestStreamingPreservesColoredRLE verifica l'integrazione end-to-end: un
+// frame colorato compresso con CompressionRLE (che per questo charset usa la
+// RLE cell-aware) deve tornare intatto dal Decoder con il proprio Charset.
+func TestStreamingPreservesColoredRLE(t *testing.T) {
+	cells := []Cell{
+		{Rune: 'x', Fg: 1, Bg: 0}, {Rune: 'x', Fg: 1, Bg: 0}, {Rune: 'x', Fg: 2, Bg: 0},
+	}
+	frame := NewFrameFromCells(ansiColorCharset{}, cells)
+
+	header := newHeader()
+	header.Compression = CompressionRLE
+
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, header)
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	if err := enc.WriteFrame(frame); err != nil {
+		t.Fatalf("WriteFrame: %v", err)
+	}
+
+	dec, err := NewDecoder(&buf)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	got, err := dec.NextFrame()
+	if err != nil {
+		t.Fatalf("NextFrame: %v", err)
+	}
+	if got.Charset != CharsetANSIColor {
+		t.Fatalf("Charset = %d, want %d", got.Charset, CharsetANSIColor)
+	}
+
+	gotCells, err := got.Cells()
+	if err != nil {
+		t.Fatalf("Cells: %v", err)
+	}
+	if len(gotCells) != len(cells) {
+		t.Fatalf("got %d cells, want %d", len(gotCells), len(cells))
+	}
+	for i, c := range cells {
+		if gotCells[i] != c {
+			t.Errorf("cell %d = %+v, want %+v", i, gotCells[i], c)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package ascv
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Valori noti per Header.Compression e per il byte di codec per-frame.
+const (
+	CompressionNone    uint8 = 0
+	CompressionRLE     uint8 = 1
+	CompressionZlib    uint8 = 2
+	CompressionDeflate uint8 = 3
+)
+
+// Codec comprime e decomprime il contenuto di un frame. Implementazioni
+// aggiuntive (LZ4, zstd, ...) possono essere registrate con RegisterCodec.
+type Codec interface {
+	Encode(data []byte) []byte
+	Decode(data []byte) ([]byte, error)
+	ID() uint8
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[uint8]Codec{}
+)
+
+// RegisterCodec registra un Codec per il relativo id, sovrascrivendo un
+// eventuale codec già registrato per lo stesso id.
+func RegisterCodec(id uint8, c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[id] = c
+}
+
+// codecFor restituisce il Codec registrato per id, se presente.
+func codecFor(id uint8) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[id]
+	return c, ok
+}
+
+func init() {
+	RegisterCodec(CompressionNone, noneCodec{})
+	RegisterCodec(CompressionRLE, rleCodec{})
+	RegisterCodec(CompressionZlib, zlibCodec{})
+	RegisterCodec(CompressionDeflate, deflateCodec{})
+}
+
+// encodeFrameContent comprime content con il codec codecID. Per
+// CompressionRLE, se charsetID individua un Charset con celle di più di un
+// byte, usa EncodeCellRLE invece del rleCodec byte-a-byte: altrimenti un
+// run-length su un charset colorato spezzerebbe un record di cella a metà,
+// collassando glifi uguali ma di colore diverso come se fossero ripetuti.
+func encodeFrameContent(codecID, charsetID uint8, content []byte) ([]byte, error) {
+	if codecID == CompressionRLE {
+		if cs, ok := charsetFor(charsetID); ok && cs.CellSize() > 1 {
+			return EncodeCellRLE(cs.CellSize(), content), nil
+		}
+	}
+	codec, ok := codecFor(codecID)
+	if !ok {
+		return nil, fmt.Errorf("ascv: unknown compression codec %d", codecID)
+	}
+	return codec.Encode(content), nil
+}
+
+// decodeFrameContent è l'inverso di encodeFrameContent.
+func decodeFrameContent(codecID, charsetID uint8, encoded []byte) ([]byte, error) {
+	if codecID == CompressionRLE {
+		if cs, ok := charsetFor(charsetID); ok && cs.CellSize() > 1 {
+			return DecodeCellRLE(cs.CellSize(), encoded)
+		}
+	}
+	codec, ok := codecFor(codecID)
+	if !ok {
+		return nil, fmt.Errorf("ascv: unknown compression codec %d", codecID)
+	}
+	return codec.Decode(encoded)
+}
+
+// noneCodec lascia i dati inalterati.
+type noneCodec struct{}
+
+func (noneCodec) ID() uint8                 { return CompressionNone }
+func (noneCodec) Encode(data []byte) []byte { return data }
+func (noneCodec) Decode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// rleCodec si appoggia a EncodeRLE/DecodeRLE.
+type rleCodec struct{}
+
+func (rleCodec) ID() uint8                 { return CompressionRLE }
+func (rleCodec) Encode(data []byte) []byte { return EncodeRLE(data) }
+func (rleCodec) Decode(data []byte) ([]byte, error) {
+	return DecodeRLE(data)
+}
+
+// zlibCodec comprime con compress/zlib.
+type zlibCodec struct{}
+
+func (zlibCodec) ID() uint8 { return CompressionZlib }
+
+func (zlibCodec) Encode(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func (zlibCodec) Decode(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("zlib codec: %w", err)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// deflateCodec comprime con compress/flate, senza l'header zlib.
+type deflateCodec struct{}
+
+func (deflateCodec) ID() uint8 { return CompressionDeflate }
+
+func (deflateCodec) Encode(data []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func (deflateCodec) Decode(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return io.ReadAll(r)
+}
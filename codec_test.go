@@ -0,0 +1,75 @@
+package ascv
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCodecsRoundTrip(t *testing.T) {
+	data := []byte("aaaaabbbbbccccc")
+	for _, id := range []uint8{CompressionNone, CompressionRLE, CompressionZlib, CompressionDeflate} {
+		codec, ok := codecFor(id)
+		if !ok {
+			t.Fatalf("codecFor(%d): not registered", id)
+		}
+		encoded := codec.Encode(data)
+		decoded, err := codec.Decode(encoded)
+		if err != nil {
+			t.Fatalf("codec %d Decode: %v", id, err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Errorf("codec %d round trip = %q, want %q", id, decoded, data)
+		}
+	}
+}
+
+// TestWriteFrameWithCodecMixedCodecsPerFrame verifica che ogni frame porti
+// con sé il proprio codec, a prescindere da header.Compression: il caso
+// d'uso di codec misti nello stesso file menzionato dalla richiesta.
+func TestWriteFrameWithCodecMixedCodecsPerFrame(t *testing.T) {
+	header := newHeader()
+	header.Compression = CompressionZlib
+
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, header)
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+
+	frames := []struct {
+		content []byte
+		codec   uint8
+	}{
+		{[]byte("aaaaaaaaaa"), CompressionRLE},
+		{[]byte("hello world"), CompressionZlib},
+		{[]byte("raw"), CompressionNone},
+	}
+	for _, f := range frames {
+		if err := enc.WriteFrameWithCodec(Frame{Size: len(f.content), Content: f.content}, f.codec); err != nil {
+			t.Fatalf("WriteFrameWithCodec: %v", err)
+		}
+	}
+
+	dec, err := NewDecoder(&buf)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	for i, f := range frames {
+		got, err := dec.NextFrame()
+		if err != nil {
+			t.Fatalf("NextFrame %d: %v", i, err)
+		}
+		if !bytes.Equal(got.Content, f.content) {
+			t.Errorf("frame %d = %q, want %q", i, got.Content, f.content)
+		}
+	}
+}
+
+func TestRegisterCodecCustomID(t *testing.T) {
+	const customID uint8 = 200
+	RegisterCodec(customID, noneCodec{})
+	codec, ok := codecFor(customID)
+	if !ok || codec.ID() != CompressionNone {
+		t.Fatalf("RegisterCodec did not register codec %d", customID)
+	}
+}
@@ -0,0 +1,120 @@
+package ascv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Valori noti per il byte di tipo-frame nel preambolo: distinguono un
+// keyframe (contenuto completo) da un DeltaFrame (solo le differenze
+// rispetto al keyframe o delta precedente).
+const (
+	FrameTypeKey   uint8 = 0
+	FrameTypeDelta uint8 = 1
+)
+
+// DeltaFrame rappresenta un frame codificato come differenza rispetto al
+// frame precedente, invece che come contenuto completo. Il suo Content è
+// prodotto da EncodeDelta e va riapplicato con ApplyDelta. Encoder lo usa
+// internamente: i chiamanti continuano a passare un Frame a WriteFrame, che
+// decide da sé, in base a header.KeyframeInterval, se scriverlo come
+// keyframe o come DeltaFrame rispetto al frame precedente.
+type DeltaFrame struct {
+	Size    int
+	Content []byte
+}
+
+// newDeltaFrame calcola il DeltaFrame di cur rispetto a prev.
+func newDeltaFrame(prev, cur []byte) DeltaFrame {
+	content := EncodeDelta(prev, cur)
+	return DeltaFrame{Size: len(content), Content: content}
+}
+
+// EncodeDelta confronta cur con prev e restituisce un vlq_target_length
+// seguito da una sequenza di triple (vlq_gap, vlq_length, payload) per ogni
+// run di byte che differisce: gap è il numero di byte invariati dalla fine
+// del run precedente (o dall'inizio del frame per il primo run), length la
+// lunghezza del run e payload i byte di cur in quel run. target_length è
+// len(cur) e va applicato prima delle triple, così ApplyDelta sa troncare o
+// estendere prev anche quando cur non ha la stessa lunghezza, invece di
+// dedurre (erroneamente) la lunghezza del frame dalle sole triple. I lunghi
+// tratti invariati tipici delle animazioni in terminale costano così solo
+// due VLQ.
+func EncodeDelta(prev, cur []byte) []byte {
+	var buffer bytes.Buffer
+	buffer.Write(encodeVLQ(uint32(len(cur))))
+
+	n := len(cur)
+	pos := 0
+	for pos < n {
+		start := pos
+		for start < n && unchanged(prev, cur, start) {
+			start++
+		}
+		if start >= n {
+			break
+		}
+
+		end := start
+		for end < n && !unchanged(prev, cur, end) {
+			end++
+		}
+
+		buffer.Write(encodeVLQ(uint32(start - pos)))
+		buffer.Write(encodeVLQ(uint32(end - start)))
+		buffer.Write(cur[start:end])
+
+		pos = end
+	}
+	return buffer.Bytes()
+}
+
+// ApplyDelta riapplica un delta prodotto da EncodeDelta a prev, restituendo
+// il frame completo originale. La lunghezza del risultato è quella
+// codificata in testa al delta, non len(prev): un prev più lungo di cur
+// viene troncato, uno più corto esteso con zeri finché le triple non lo
+// ricoprono.
+func ApplyDelta(prev, delta []byte) ([]byte, error) {
+	r := bytes.NewReader(delta)
+	targetLen, err := decodeVLQ(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cur := make([]byte, targetLen)
+	copy(cur, prev)
+
+	pos := 0
+	for r.Len() > 0 {
+		gap, err := decodeVLQ(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		length, err := decodeVLQ(r)
+		if err != nil {
+			return nil, err
+		}
+
+		pos += int(gap)
+		end := pos + int(length)
+		if end > len(cur) {
+			return nil, fmt.Errorf("ascv: delta run ends at %d beyond target length %d", end, len(cur))
+		}
+		if _, err := io.ReadFull(r, cur[pos:end]); err != nil {
+			return nil, err
+		}
+		pos = end
+	}
+	return cur, nil
+}
+
+// unchanged riporta se cur[i] è identico a prev[i], trattando un prev più
+// corto come se terminasse con byte sempre diversi da cur.
+func unchanged(prev, cur []byte, i int) bool {
+	return i < len(prev) && prev[i] == cur[i]
+}
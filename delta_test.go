@@ -0,0 +1,73 @@
+package ascv
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeApplyDeltaRoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		prev string
+		cur  string
+	}{
+		{"identical", "AAAAAAAAAA", "AAAAAAAAAA"},
+		{"single change", "AAAAAAAAAA", "AAABAAAAAA"},
+		{"shrink", "AAAAAAAAAA", "AAA"}, // prev più lungo di cur: regressione coperta da chunk0-3
+		{"grow", "AAA", "AAAAAAAAAA"},
+		{"empty prev", "", "hello"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			delta := EncodeDelta([]byte(c.prev), []byte(c.cur))
+			got, err := ApplyDelta([]byte(c.prev), delta)
+			if err != nil {
+				t.Fatalf("ApplyDelta: %v", err)
+			}
+			if string(got) != c.cur {
+				t.Errorf("ApplyDelta = %q, want %q", got, c.cur)
+			}
+		})
+	}
+}
+
+// TestEncoderDeltaFrames verifica che l'Encoder emetta da sé un keyframe
+// ogni header.KeyframeInterval frame e un DeltaFrame negli altri, e che il
+// Decoder li riapplichi in modo trasparente per il chiamante.
+func TestEncoderDeltaFrames(t *testing.T) {
+	header := newHeader()
+	header.KeyframeInterval = 3
+
+	contents := [][]byte{
+		[]byte("AAAAAAAAAA"),
+		[]byte("AAABAAAAAA"),
+		[]byte("AAABAACAAA"),
+		[]byte("BBBBBBBBBB"), // nuovo keyframe: indice 3, multiplo di 3
+		[]byte("BBBCBBBBBB"),
+	}
+
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, header)
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	for _, c := range contents {
+		if err := enc.WriteFrame(Frame{Size: len(c), Content: c}); err != nil {
+			t.Fatalf("WriteFrame: %v", err)
+		}
+	}
+
+	dec, err := NewDecoder(&buf)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	for i, want := range contents {
+		got, err := dec.NextFrame()
+		if err != nil {
+			t.Fatalf("NextFrame %d: %v", i, err)
+		}
+		if !bytes.Equal(got.Content, want) {
+			t.Errorf("frame %d = %q, want %q", i, got.Content, want)
+		}
+	}
+}
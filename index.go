@@ -0,0 +1,257 @@
+package ascv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// footerMagic chiude il file e segnala la presenza di un indice dei frame.
+const footerMagic = "IDX1"
+
+// footerSize è la dimensione del footer: offset dell'indice (8 byte) + magic.
+const footerSize = 8 + len(footerMagic)
+
+// IndexEntry descrive la posizione di un frame nel file, per l'accesso
+// casuale. Type (FrameTypeKey o FrameTypeDelta) dice a FrameAt se il frame
+// può essere decodificato da solo o se serve prima risalire al keyframe
+// precedente e riapplicare i delta intermedi.
+type IndexEntry struct {
+	FrameNumber uint32
+	Offset      uint64
+	Size        uint32
+	Type        uint8
+}
+
+// WriteIndex scrive in coda allo stream la tabella dei frame incontrati finora
+// e il footer che vi punta. Va chiamata dopo l'ultimo WriteFrame; l'indice che
+// ne risulta è opzionale: i file senza footer restano leggibili in sequenza e
+// Reader lo ricostruisce comunque con una scansione lineare.
+func (e *Encoder) WriteIndex() error {
+	indexOffset := e.offset
+	for _, entry := range e.index {
+		if err := binary.Write(e.w, binary.LittleEndian, entry); err != nil {
+			return err
+		}
+	}
+	if err := binary.Write(e.w, binary.LittleEndian, indexOffset); err != nil {
+		return err
+	}
+	_, err := e.w.Write([]byte(footerMagic))
+	return err
+}
+
+// Reader permette l'accesso casuale ai frame di un file .ascv tramite un
+// io.ReaderAt, senza dover decodificare tutti i frame precedenti.
+type Reader struct {
+	ra     io.ReaderAt
+	size   int64
+	header Header
+	index  []IndexEntry
+	// Verify abilita il controllo del checksum per-frame in FrameAt.
+	Verify bool
+}
+
+// NewReader legge e valida l'header da ra e restituisce un Reader pronto
+// per FrameAt e Seek. size è la dimensione totale del contenuto leggibile
+// tramite ra (ad es. il risultato di (*os.File).Stat).
+func NewReader(ra io.ReaderAt, size int64) (*Reader, error) {
+	hdrSize := binary.Size(Header{})
+	buf := make([]byte, hdrSize)
+	if _, err := ra.ReadAt(buf, 0); err != nil {
+		return nil, err
+	}
+
+	var header Header
+	if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if string(header.Magic[:]) != Magic {
+		return nil, errors.New("invalid file format")
+	}
+
+	return &Reader{ra: ra, size: size, header: header}, nil
+}
+
+// Header restituisce l'header letto da NewReader.
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// NumFrames restituisce il numero di frame nel file, costruendo l'indice
+// al primo utilizzo se non è già stato caricato.
+func (r *Reader) NumFrames() int {
+	if err := r.ensureIndex(); err != nil {
+		return 0
+	}
+	return len(r.index)
+}
+
+// FrameAt decodifica e restituisce il frame n, saltando direttamente al suo
+// offset nel file grazie all'indice. Se n è un DeltaFrame, risale al
+// keyframe non-delta più vicino che lo precede e riapplica in sequenza i
+// delta intermedi: più lento di un accesso diretto, ma comunque evita di
+// decodificare l'intero file come farebbe una lettura sequenziale.
+func (r *Reader) FrameAt(n int) (Frame, error) {
+	if err := r.ensureIndex(); err != nil {
+		return Frame{}, err
+	}
+	if n < 0 || n >= len(r.index) {
+		return Frame{}, fmt.Errorf("ascv: frame %d out of range", n)
+	}
+
+	start := n
+	for start > 0 && r.index[start].Type == FrameTypeDelta {
+		start--
+	}
+
+	var frame Frame
+	var prevContent []byte
+	for i := start; i <= n; i++ {
+		entry := r.index[i]
+		sr := io.NewSectionReader(r.ra, int64(entry.Offset), int64(entry.Size))
+		raw, err := decodeRawFrame(sr, r.header.Checksum, r.Verify)
+		if err != nil {
+			if ce, ok := err.(*ChecksumError); ok {
+				ce.Frame = i
+			}
+			return Frame{}, err
+		}
+
+		content := raw.Content
+		if raw.Type == FrameTypeDelta {
+			content, err = ApplyDelta(prevContent, raw.Content)
+			if err != nil {
+				return Frame{}, err
+			}
+		}
+		prevContent = content
+		frame = Frame{Size: len(content), Content: content, Charset: raw.Charset}
+	}
+	return frame, nil
+}
+
+// Seek è un alias di FrameAt pensato per un player: posiziona la riproduzione
+// sul frame n e lo restituisce già decodificato.
+func (r *Reader) Seek(frame int) (Frame, error) {
+	return r.FrameAt(frame)
+}
+
+// ensureIndex carica l'indice dal footer in coda al file se presente,
+// altrimenti lo ricostruisce scansionando i frame in sequenza.
+func (r *Reader) ensureIndex() error {
+	if r.index != nil {
+		return nil
+	}
+
+	entries, ok, err := r.readFooterIndex()
+	if err != nil {
+		return err
+	}
+	if ok {
+		r.index = entries
+		return nil
+	}
+
+	return r.buildIndexByScanning()
+}
+
+func (r *Reader) readFooterIndex() ([]IndexEntry, bool, error) {
+	if r.size < int64(footerSize) {
+		return nil, false, nil
+	}
+
+	trailer := make([]byte, footerSize)
+	if _, err := r.ra.ReadAt(trailer, r.size-int64(footerSize)); err != nil {
+		return nil, false, err
+	}
+	if string(trailer[8:]) != footerMagic {
+		return nil, false, nil
+	}
+
+	indexOffset := int64(binary.LittleEndian.Uint64(trailer[:8]))
+	entrySize := int64(binary.Size(IndexEntry{}))
+	indexBytes := r.size - int64(footerSize) - indexOffset
+	if indexOffset < 0 || indexBytes < 0 || indexBytes%entrySize != 0 {
+		return nil, false, nil
+	}
+
+	buf := make([]byte, indexBytes)
+	if _, err := r.ra.ReadAt(buf, indexOffset); err != nil {
+		return nil, false, err
+	}
+
+	rr := bytes.NewReader(buf)
+	entries := make([]IndexEntry, indexBytes/entrySize)
+	for i := range entries {
+		if err := binary.Read(rr, binary.LittleEndian, &entries[i]); err != nil {
+			return nil, false, err
+		}
+	}
+	return entries, true, nil
+}
+
+// buildIndexByScanning legge sequenzialmente tutti i frame per ricostruire
+// l'indice quando il file non ha un footer. Il numero di frame letti è
+// limitato a header.Frames quando valorizzato, e la scansione si ferma
+// comunque al marcatore frameTypeEnd scritto da WriteDigest, così il digest
+// eventualmente scritto in coda all'ultimo frame non viene scambiato per un
+// frame in più.
+func (r *Reader) buildIndexByScanning() error {
+	offset := int64(binary.Size(Header{}))
+	maxFrames := int(r.header.Frames)
+
+	var entries []IndexEntry
+	for offset < r.size && (maxFrames == 0 || len(entries) < maxFrames) {
+		cr := &countingReader{r: io.NewSectionReader(r.ra, offset, r.size-offset)}
+
+		var typeByte [1]byte
+		if _, err := io.ReadFull(cr, typeByte[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		if typeByte[0] == frameTypeEnd {
+			break
+		}
+
+		size, err := decodeVLQ(cr)
+		if err != nil {
+			return requireFrame(err)
+		}
+
+		var preamble [2]byte // codec id, charset id
+		if _, err := io.ReadFull(cr, preamble[:]); err != nil {
+			return requireFrame(err)
+		}
+
+		total := cr.n + int64(size) + int64(checksumSize(r.header.Checksum))
+		entries = append(entries, IndexEntry{
+			FrameNumber: uint32(len(entries)),
+			Offset:      uint64(offset),
+			Size:        uint32(total),
+			Type:        typeByte[0],
+		})
+		offset += total
+	}
+
+	r.index = entries
+	return nil
+}
+
+// countingReader conta i byte letti da r, per sapere quanto è lungo un
+// preambolo di lunghezza variabile (tipo, VLQ, byte di codec e di charset)
+// durante la scansione.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,138 @@
+package ascv
+
+import (
+	"bytes"
+	"testing"
+)
+
+func buildASCVBytes(t *testing.T, header Header, frames []Frame, writeIndex bool) []byte {
+	t.Helper()
+	header.Frames = uint32(len(frames))
+
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, header)
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	for _, f := range frames {
+		if err := enc.WriteFrame(f); err != nil {
+			t.Fatalf("WriteFrame: %v", err)
+		}
+	}
+	if err := enc.WriteDigest(); err != nil {
+		t.Fatalf("WriteDigest: %v", err)
+	}
+	if writeIndex {
+		if err := enc.WriteIndex(); err != nil {
+			t.Fatalf("WriteIndex: %v", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestReaderFrameAtWithFooterIndex(t *testing.T) {
+	frames := []Frame{
+		{Size: 1, Content: []byte("a")},
+		{Size: 1, Content: []byte("b")},
+		{Size: 1, Content: []byte("c")},
+	}
+	data := buildASCVBytes(t, newHeader(), frames, true)
+
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if n := r.NumFrames(); n != len(frames) {
+		t.Fatalf("NumFrames = %d, want %d", n, len(frames))
+	}
+	for i := len(frames) - 1; i >= 0; i-- { // ordine inverso: deve saltare, non scansionare da 0
+		f, err := r.FrameAt(i)
+		if err != nil {
+			t.Fatalf("FrameAt(%d): %v", i, err)
+		}
+		if !bytes.Equal(f.Content, frames[i].Content) {
+			t.Errorf("FrameAt(%d) = %q, want %q", i, f.Content, frames[i].Content)
+		}
+	}
+}
+
+func TestReaderFrameAtWithoutFooterScans(t *testing.T) {
+	frames := []Frame{
+		{Size: 1, Content: []byte("x")},
+		{Size: 1, Content: []byte("y")},
+	}
+	data := buildASCVBytes(t, newHeader(), frames, false)
+
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	f, err := r.Seek(1)
+	if err != nil {
+		t.Fatalf("Seek(1): %v", err)
+	}
+	if !bytes.Equal(f.Content, frames[1].Content) {
+		t.Errorf("Seek(1) = %q, want %q", f.Content, frames[1].Content)
+	}
+}
+
+// TestReaderFrameAtReplaysDeltas verifica che l'accesso casuale funzioni
+// anche quando il frame richiesto è un DeltaFrame: FrameAt deve risalire al
+// keyframe precedente e riapplicare i delta intermedi da sé.
+func TestReaderFrameAtReplaysDeltas(t *testing.T) {
+	header := newHeader()
+	header.KeyframeInterval = 2
+
+	contents := [][]byte{
+		[]byte("AAAAAAAAAA"),
+		[]byte("AAABAAAAAA"),
+		[]byte("CCCCCCCCCC"),
+		[]byte("CCCDCCCCCC"),
+	}
+	var frames []Frame
+	for _, c := range contents {
+		frames = append(frames, Frame{Size: len(c), Content: c})
+	}
+	data := buildASCVBytes(t, header, frames, true)
+
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	for i, want := range contents {
+		f, err := r.FrameAt(i)
+		if err != nil {
+			t.Fatalf("FrameAt(%d): %v", i, err)
+		}
+		if !bytes.Equal(f.Content, want) {
+			t.Errorf("FrameAt(%d) = %q, want %q", i, f.Content, want)
+		}
+	}
+}
+
+func TestReaderFrameAtRestoresCharset(t *testing.T) {
+	cells := []Cell{{Rune: 'x', Fg: 1, Bg: 2}, {Rune: 'y', Fg: 3, Bg: 4}}
+	frame := NewFrameFromCells(ansiColorCharset{}, cells)
+
+	data := buildASCVBytes(t, newHeader(), []Frame{frame}, true)
+
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	got, err := r.FrameAt(0)
+	if err != nil {
+		t.Fatalf("FrameAt(0): %v", err)
+	}
+	if got.Charset != CharsetANSIColor {
+		t.Fatalf("FrameAt(0).Charset = %d, want %d", got.Charset, CharsetANSIColor)
+	}
+
+	gotCells, err := got.Cells()
+	if err != nil {
+		t.Fatalf("Cells: %v", err)
+	}
+	if len(gotCells) != len(cells) || gotCells[0] != cells[0] || gotCells[1] != cells[1] {
+		t.Errorf("Cells = %+v, want %+v", gotCells, cells)
+	}
+}
@@ -0,0 +1,78 @@
+package ascv
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+)
+
+// Valori noti per Header.Checksum.
+const (
+	ChecksumNone        uint8 = 0
+	ChecksumCRC32       uint8 = 1
+	ChecksumCRC64       uint8 = 2
+	ChecksumSHA256Trunc uint8 = 3
+)
+
+// digestSize è la dimensione, in byte, del digest complessivo di file
+// scritto in coda all'ultimo frame (indipendente dal Checksum per-frame).
+const digestSize = sha256.Size
+
+var crc64Table = crc64.MakeTable(crc64.ISO)
+
+// ChecksumError segnala un frame (o l'intero file, con Frame == -1) il cui
+// checksum memorizzato non corrisponde a quello ricalcolato.
+type ChecksumError struct {
+	Frame int
+	Want  []byte
+	Got   []byte
+}
+
+func (e *ChecksumError) Error() string {
+	if e.Frame < 0 {
+		return fmt.Sprintf("ascv: file digest mismatch: want %x, got %x", e.Want, e.Got)
+	}
+	return fmt.Sprintf("ascv: checksum mismatch on frame %d: want %x, got %x", e.Frame, e.Want, e.Got)
+}
+
+// checksumSize restituisce la dimensione in byte del checksum per-frame per
+// l'algoritmo kind, o 0 se kind non richiede alcun checksum.
+func checksumSize(kind uint8) int {
+	switch kind {
+	case ChecksumCRC32:
+		return 4
+	case ChecksumCRC64:
+		return 8
+	case ChecksumSHA256Trunc:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// computeChecksum calcola il checksum di data secondo l'algoritmo kind.
+func computeChecksum(kind uint8, data []byte) []byte {
+	switch kind {
+	case ChecksumCRC32:
+		buf := make([]byte, 4)
+		binary.LittleEndian.PutUint32(buf, crc32.ChecksumIEEE(data))
+		return buf
+	case ChecksumCRC64:
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, crc64.Checksum(data, crc64Table))
+		return buf
+	case ChecksumSHA256Trunc:
+		sum := sha256.Sum256(data)
+		return sum[:8]
+	default:
+		return nil
+	}
+}
+
+// newFileDigest restituisce l'hash usato per il digest complessivo del file.
+func newFileDigest() hash.Hash {
+	return sha256.New()
+}
@@ -0,0 +1,103 @@
+package ascv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyASCVDetectsCorruption(t *testing.T) {
+	header := newHeader()
+	header.Checksum = ChecksumCRC32
+	frames := []Frame{{Size: 3, Content: []byte("abc")}}
+
+	path := filepath.Join(t.TempDir(), "anim.ascv")
+	if err := WriteASCV(path, header, frames); err != nil {
+		t.Fatalf("WriteASCV: %v", err)
+	}
+	if err := VerifyASCV(path); err != nil {
+		t.Fatalf("VerifyASCV on untouched file: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	data[len(data)-1] ^= 0xFF // flip l'ultimo byte, dentro al digest finale
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	err = VerifyASCV(path)
+	var checksumErr *ChecksumError
+	if !errors.As(err, &checksumErr) {
+		t.Fatalf("VerifyASCV on corrupted file = %v, want *ChecksumError", err)
+	}
+}
+
+// TestNextFrameCleanEOFWithUnknownFrameCount riproduce lo scenario di uno
+// stream di lunghezza ignota a priori (header.Frames lasciato a 0, come su
+// un socket o uno stdout) con un checksum complessivo abilitato: NextFrame
+// deve restituire io.EOF pulito invece di provare a leggere il digest come
+// se fosse un frame in più.
+func TestNextFrameCleanEOFWithUnknownFrameCount(t *testing.T) {
+	header := newHeader()
+	header.Checksum = ChecksumCRC32
+	// header.Frames resta volutamente a 0.
+
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, header)
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	frames := []Frame{
+		{Size: 1, Content: []byte("a")},
+		{Size: 1, Content: []byte("b")},
+	}
+	for _, f := range frames {
+		if err := enc.WriteFrame(f); err != nil {
+			t.Fatalf("WriteFrame: %v", err)
+		}
+	}
+	if err := enc.WriteDigest(); err != nil {
+		t.Fatalf("WriteDigest: %v", err)
+	}
+
+	dec, err := NewDecoder(&buf)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	for i, want := range frames {
+		f, err := dec.NextFrame()
+		if err != nil {
+			t.Fatalf("NextFrame %d: %v", i, err)
+		}
+		if !bytes.Equal(f.Content, want.Content) {
+			t.Errorf("frame %d = %q, want %q", i, f.Content, want.Content)
+		}
+	}
+
+	if _, err := dec.NextFrame(); !errors.Is(err, io.EOF) {
+		t.Fatalf("NextFrame after last frame = %v, want io.EOF", err)
+	}
+	if err := dec.VerifyDigest(); err != nil {
+		t.Fatalf("VerifyDigest: %v", err)
+	}
+}
+
+func TestChecksumSizes(t *testing.T) {
+	want := map[uint8]int{
+		ChecksumNone:        0,
+		ChecksumCRC32:       4,
+		ChecksumCRC64:       8,
+		ChecksumSHA256Trunc: 8,
+	}
+	for kind, size := range want {
+		if got := checksumSize(kind); got != size {
+			t.Errorf("checksumSize(%d) = %d, want %d", kind, got, size)
+		}
+	}
+}
@@ -0,0 +1,473 @@
+package ascv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// Encoder scrive un header e una sequenza di frame su un io.Writer,
+// senza dover tenere l'intera animazione in memoria.
+type Encoder struct {
+	w           io.Writer // destinazione effettiva di ogni scrittura (include il digest, se abilitato)
+	raw         io.Writer // writer originale passato a NewEncoder
+	header      Header
+	offset      uint64
+	index       []IndexEntry
+	digest      hash.Hash // non nil se header.Checksum != ChecksumNone
+	frameIndex  int       // numero di frame scritti finora, per decidere keyframe vs delta
+	prevContent []byte    // Content del frame precedente, non compresso, per il prossimo delta
+}
+
+// NewEncoder scrive l'header su w e restituisce un Encoder pronto a
+// ricevere i frame tramite WriteFrame. Se header.Checksum non è
+// ChecksumNone, ogni byte scritto (header compreso) alimenta anche il
+// digest complessivo del file, emesso da WriteDigest dopo l'ultimo frame.
+func NewEncoder(w io.Writer, header Header) (*Encoder, error) {
+	e := &Encoder{raw: w, w: w, header: header}
+	if header.Checksum != ChecksumNone {
+		e.digest = newFileDigest()
+		e.w = io.MultiWriter(w, e.digest)
+	}
+
+	if err := binary.Write(e.w, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+	e.offset = uint64(binary.Size(header))
+	return e, nil
+}
+
+// WriteFrame comprime e scrive un frame usando il codec di default
+// dell'header (header.Compression).
+func (e *Encoder) WriteFrame(frame Frame) error {
+	return e.WriteFrameWithCodec(frame, e.header.Compression)
+}
+
+// WriteFrameWithCodec comprime e scrive un frame con un codec specifico,
+// a prescindere dal default dell'header. Permette file a codec misto: ogni
+// frame porta con sé, nel suo preambolo, l'id del codec usato per scriverlo.
+//
+// Se header.KeyframeInterval è diverso da zero, l'Encoder scrive da sé un
+// keyframe ogni KeyframeInterval frame e un DeltaFrame rispetto al
+// contenuto precedente negli altri, secondo frameIndex: il chiamante passa
+// sempre il contenuto completo del frame, non il delta.
+func (e *Encoder) WriteFrameWithCodec(frame Frame, codecID uint8) error {
+	frameType := FrameTypeKey
+	storedContent := frame.Content
+	codecCharset := frame.Charset
+	if e.header.KeyframeInterval > 0 && e.frameIndex > 0 && e.frameIndex%int(e.header.KeyframeInterval) != 0 {
+		frameType = FrameTypeDelta
+		storedContent = newDeltaFrame(e.prevContent, frame.Content).Content
+		codecCharset = CharsetASCII // i delta non sono record di cella: niente RLE cell-aware
+	}
+
+	encoded, err := encodeFrameContent(codecID, codecCharset, storedContent)
+	if err != nil {
+		return err
+	}
+	preamble := encodeVLQ(uint32(len(encoded)))
+	checksum := computeChecksum(e.header.Checksum, encoded)
+
+	start := e.offset
+	if _, err := e.w.Write([]byte{frameType}); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(preamble); err != nil {
+		return err
+	}
+	if _, err := e.w.Write([]byte{codecID, frame.Charset}); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(encoded); err != nil {
+		return err
+	}
+	if len(checksum) > 0 {
+		if _, err := e.w.Write(checksum); err != nil {
+			return err
+		}
+	}
+
+	total := 1 + uint64(len(preamble)) + 2 + uint64(len(encoded)) + uint64(len(checksum))
+	e.index = append(e.index, IndexEntry{
+		FrameNumber: uint32(len(e.index)),
+		Offset:      start,
+		Size:        uint32(total),
+		Type:        frameType,
+	})
+	e.offset += total
+
+	e.prevContent = append([]byte(nil), frame.Content...)
+	e.frameIndex++
+	return nil
+}
+
+// frameTypeEnd è un valore di tag riservato, mai usato per un frame vero:
+// WriteDigest lo scrive subito prima del digest per rendere lo stream
+// auto-delimitante. Senza questo marcatore, un Decoder con header.Frames
+// non valorizzato (lo scenario di uno stream non bufferizzato e di
+// lunghezza ignota motivato da NewEncoder/NewDecoder) proverebbe a leggere
+// il digest stesso come se fosse il preambolo di un frame in più.
+const frameTypeEnd uint8 = 0xFF
+
+// maxFrameSize è il limite superiore accettato per la dimensione (compressa)
+// di un singolo frame dichiarata nel preambolo VLQ. Senza un tetto, un
+// preambolo artefatto con una size enorme farebbe allocare a decodeRawFrame
+// un buffer di quella dimensione prima ancora di leggerne un byte:
+// esattamente il tipo di DoS a cui è esposto lo scenario "stream di rete/
+// handler HTTP" motivato da NewEncoder/NewDecoder.
+const maxFrameSize = 64 << 20 // 64 MiB
+
+// WriteDigest calcola e scrive, in coda all'ultimo frame, il digest SHA-256
+// dell'intero file scritto finora (header e frame compresi). Va chiamata
+// dopo l'ultimo WriteFrame e prima di un eventuale WriteIndex. Non fa nulla
+// se header.Checksum è ChecksumNone.
+func (e *Encoder) WriteDigest() error {
+	if e.digest == nil {
+		return nil
+	}
+	if _, err := e.w.Write([]byte{frameTypeEnd}); err != nil {
+		return err
+	}
+	e.offset++
+
+	sum := e.digest.Sum(nil)
+	n, err := e.raw.Write(sum)
+	e.offset += uint64(n)
+	return err
+}
+
+// Close chiude lo stream sottostante se implementa io.Closer.
+func (e *Encoder) Close() error {
+	if c, ok := e.raw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Decoder legge un header e una sequenza di frame da un io.Reader.
+type Decoder struct {
+	src         io.Reader // stream originale, per le letture successive all'ultimo frame
+	r           io.Reader // src, sempre "teed" verso digest
+	header      Header
+	digest      hash.Hash // sempre presente; VerifyDigest lo confronta solo se header.Checksum != ChecksumNone
+	frameCount  int
+	prevContent []byte // Content del frame precedente, per applicare il prossimo eventuale DeltaFrame
+	sawEnd      bool    // true se NextFrame ha ricevuto io.EOF dallo stream (di norma dal marcatore frameTypeEnd di WriteDigest)
+	// Verify abilita il controllo dei checksum per-frame in NextFrame;
+	// il digest complessivo va invece verificato con VerifyDigest.
+	Verify bool
+}
+
+// NewDecoder legge e valida l'header da r e restituisce un Decoder
+// pronto a restituire i frame tramite NextFrame.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	digest := newFileDigest()
+	tee := io.TeeReader(r, digest)
+
+	var header Header
+	if err := binary.Read(tee, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if string(header.Magic[:]) != Magic {
+		return nil, errors.New("invalid file format")
+	}
+	return &Decoder{src: r, r: tee, header: header, digest: digest}, nil
+}
+
+// Header restituisce l'header letto da NewDecoder.
+func (d *Decoder) Header() Header {
+	return d.header
+}
+
+// NextFrame legge il prossimo frame dallo stream, decomprimendolo con il
+// codec indicato nel suo preambolo, e restituisce io.EOF quando non ci sono
+// altri frame da leggere. Un DeltaFrame viene riapplicato al frame
+// precedente prima di essere restituito, così il chiamante vede sempre un
+// Frame col contenuto completo. Se Verify è true, un checksum per-frame che
+// non corrisponde fa restituire un *ChecksumError.
+//
+// Se header.Frames è valorizzato, NextFrame si ferma dopo quel numero di
+// frame invece di affidarsi alla sola fine dello stream: è ciò che permette
+// a un digest o a un indice, scritti in coda all'ultimo frame, di non
+// essere scambiati per l'inizio di un frame successivo.
+func (d *Decoder) NextFrame() (Frame, error) {
+	if d.header.Frames > 0 && uint32(d.frameCount) >= d.header.Frames {
+		return Frame{}, io.EOF
+	}
+
+	raw, err := decodeRawFrame(d.r, d.header.Checksum, d.Verify)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			d.sawEnd = true
+		}
+		if ce, ok := err.(*ChecksumError); ok {
+			ce.Frame = d.frameCount
+		}
+		return Frame{}, err
+	}
+
+	content := raw.Content
+	if raw.Type == FrameTypeDelta {
+		content, err = ApplyDelta(d.prevContent, raw.Content)
+		if err != nil {
+			return Frame{}, err
+		}
+	}
+
+	d.prevContent = content
+	d.frameCount++
+	return Frame{Size: len(content), Content: content, Charset: raw.Charset}, nil
+}
+
+// VerifyDigest legge il digest complessivo del file scritto subito dopo
+// l'ultimo frame e lo confronta con quello ricalcolato durante la lettura
+// (header e frame compresi). Va chiamata dopo aver esaurito NextFrame. Non
+// fa nulla se l'header ha Checksum uguale a ChecksumNone.
+func (d *Decoder) VerifyDigest() error {
+	if d.header.Checksum == ChecksumNone {
+		return nil
+	}
+
+	// Se NextFrame si è fermato per header.Frames invece che per aver letto
+	// il marcatore frameTypeEnd (il caso comune: WriteASCV valorizza sempre
+	// Frames), il marcatore è ancora da consumare prima del digest vero e
+	// proprio. Va letto da d.r, non da d.src, per restare incluso nel
+	// digest ricalcolato come lo è in quello scritto da WriteDigest.
+	if !d.sawEnd {
+		var marker [1]byte
+		if _, err := io.ReadFull(d.r, marker[:]); err != nil {
+			return err
+		}
+	}
+
+	want := make([]byte, digestSize)
+	if _, err := io.ReadFull(d.src, want); err != nil {
+		return err
+	}
+
+	got := d.digest.Sum(nil)
+	if !bytes.Equal(want, got) {
+		return &ChecksumError{Frame: -1, Want: want, Got: got}
+	}
+	return nil
+}
+
+// rawFrame è quanto decodeRawFrame legge dal flusso, prima che un eventuale
+// DeltaFrame venga riapplicato al frame precedente.
+type rawFrame struct {
+	Type    uint8
+	Charset uint8
+	// Content è il contenuto completo se Type è FrameTypeKey, oppure i byte
+	// prodotti da EncodeDelta (da passare ad ApplyDelta) se FrameTypeDelta.
+	Content []byte
+}
+
+// requireFrame converte un io.EOF pulito in io.ErrUnexpectedEOF: va usato
+// dal punto in cui decodeRawFrame ha già letto un tag di frame reale, perché
+// da lì in poi esaurire lo stream non è più una fine pulita ma un
+// troncamento a metà frame.
+func requireFrame(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// decodeRawFrame legge un singolo frame (tipo + preambolo + payload
+// compresso + eventuale checksum) da r e lo decomprime, senza applicare un
+// eventuale delta: quello spetta al chiamante, che conosce il frame
+// precedente. Usata sia da Decoder.NextFrame per la lettura sequenziale sia
+// da Reader.FrameAt per l'accesso casuale tramite indice. Il Frame del
+// ChecksumError restituito in caso di mismatch va riempito dal chiamante,
+// che conosce il numero del frame. Restituisce io.EOF, senza leggere altro,
+// se il tag di frame è frameTypeEnd: è il marcatore scritto da WriteDigest,
+// non un frame.
+func decodeRawFrame(r io.Reader, checksumKind uint8, verify bool) (rawFrame, error) {
+	var typeByte [1]byte
+	if _, err := io.ReadFull(r, typeByte[:]); err != nil {
+		return rawFrame{}, err
+	}
+	frameType := typeByte[0]
+	if frameType == frameTypeEnd {
+		return rawFrame{}, io.EOF
+	}
+
+	// Da qui in poi il tag di frame ha già promesso un frame vero (non
+	// frameTypeEnd): un io.EOF che emerge nel mezzo della sua lettura è uno
+	// stream troncato, non una fine pulita, e va segnalato come tale invece
+	// di farsi scambiare da NextFrame per "nessun altro frame".
+	size, err := decodeVLQ(r)
+	if err != nil {
+		return rawFrame{}, requireFrame(err)
+	}
+	if size > maxFrameSize {
+		return rawFrame{}, fmt.Errorf("ascv: frame size %d exceeds maximum of %d bytes", size, maxFrameSize)
+	}
+
+	var preamble [2]byte // codec id, charset id
+	if _, err := io.ReadFull(r, preamble[:]); err != nil {
+		return rawFrame{}, requireFrame(err)
+	}
+	codecID, charsetID := preamble[0], preamble[1]
+
+	encoded := make([]byte, size)
+	if _, err := io.ReadFull(r, encoded); err != nil {
+		return rawFrame{}, requireFrame(err)
+	}
+
+	if csSize := checksumSize(checksumKind); csSize > 0 {
+		want := make([]byte, csSize)
+		if _, err := io.ReadFull(r, want); err != nil {
+			return rawFrame{}, requireFrame(err)
+		}
+		if verify {
+			if got := computeChecksum(checksumKind, encoded); !bytes.Equal(want, got) {
+				return rawFrame{}, &ChecksumError{Frame: -1, Want: want, Got: got}
+			}
+		}
+	}
+
+	codecCharset := charsetID
+	if frameType == FrameTypeDelta {
+		codecCharset = CharsetASCII // i delta non sono record di cella: niente RLE cell-aware
+	}
+	content, err := decodeFrameContent(codecID, codecCharset, encoded)
+	if err != nil {
+		return rawFrame{}, err
+	}
+
+	return rawFrame{Type: frameType, Charset: charsetID, Content: content}, nil
+}
+
+// WriteASCV scrive una sequenza di frame in un file .ascv. header.Frames
+// viene impostato al numero di frame passati, così un digest o un indice
+// scritti in coda restano distinguibili da un frame in più in lettura.
+func WriteASCV(filename string, header Header, frames []Frame) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header.Frames = uint32(len(frames))
+	enc, err := NewEncoder(file, header)
+	if err != nil {
+		return err
+	}
+
+	for _, frame := range frames {
+		if err := enc.WriteFrame(frame); err != nil {
+			return err
+		}
+	}
+
+	if err := enc.WriteDigest(); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// ReadASCV legge un file .ascv e restituisce l'header e i frame.
+func ReadASCV(filename string) (Header, []Frame, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Header{}, nil, err
+	}
+	defer file.Close()
+
+	dec, err := NewDecoder(file)
+	if err != nil {
+		return Header{}, nil, err
+	}
+
+	var frames []Frame
+	for {
+		frame, err := dec.NextFrame()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return dec.Header(), nil, err
+		}
+		frames = append(frames, frame)
+	}
+
+	return dec.Header(), frames, nil
+}
+
+// VerifyASCV legge un file .ascv verificando il checksum di ogni frame e,
+// se presente, il digest complessivo del file, senza restituirne il
+// contenuto. Restituisce il primo *ChecksumError incontrato.
+func VerifyASCV(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dec, err := NewDecoder(file)
+	if err != nil {
+		return err
+	}
+	dec.Verify = true
+
+	for {
+		_, err := dec.NextFrame()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+	}
+
+	return dec.VerifyDigest()
+}
+
+// encodeVLQ codifica un uint32 nel formato VLQ (Variable-Length Quantity)
+func encodeVLQ(value uint32) []byte {
+	var buffer []byte
+	for {
+		byteValue := value & 0x7F
+		value >>= 7
+		if value > 0 {
+			buffer = append(buffer, byte(byteValue|0x80))
+		} else {
+			buffer = append(buffer, byte(byteValue))
+			break
+		}
+	}
+	return buffer
+}
+
+// decodeVLQ decodifica un valore VLQ (Variable-Length Quantity) da un io.Reader,
+// restituendo io.EOF solo quando lo stream termina in modo pulito tra due valori;
+// un EOF nel mezzo di un VLQ diventa io.ErrUnexpectedEOF, ogni altro errore
+// viene propagato com'è.
+func decodeVLQ(r io.Reader) (uint32, error) {
+	var value uint32
+	var shift uint32
+	buf := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if shift == 0 && errors.Is(err, io.EOF) {
+				return 0, io.EOF
+			}
+			if errors.Is(err, io.EOF) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		value |= uint32(buf[0]&0x7F) << shift
+		if buf[0]&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+	return value, nil
+}
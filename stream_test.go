@@ -0,0 +1,200 @@
+package ascv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newHeader restituisce un Header valido con il Magic corretto, pronto da
+// personalizzare nei singoli test.
+func newHeader() Header {
+	var h Header
+	copy(h.Magic[:], Magic)
+	return h
+}
+
+func TestEncoderDecoderRoundTrip(t *testing.T) {
+	frames := []Frame{
+		{Size: 2, Content: []byte("ab")},
+		{Size: 2, Content: []byte("cd")},
+	}
+
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, newHeader())
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	for _, f := range frames {
+		if err := enc.WriteFrame(f); err != nil {
+			t.Fatalf("WriteFrame: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	dec, err := NewDecoder(&buf)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	var got []Frame
+	for {
+		f, err := dec.NextFrame()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			t.Fatalf("NextFrame: %v", err)
+		}
+		got = append(got, f)
+	}
+
+	if len(got) != len(frames) {
+		t.Fatalf("got %d frames, want %d", len(got), len(frames))
+	}
+	for i, f := range got {
+		if !bytes.Equal(f.Content, frames[i].Content) {
+			t.Errorf("frame %d content = %q, want %q", i, f.Content, frames[i].Content)
+		}
+	}
+}
+
+// failingReader restituisce i byte di data e poi un errore scelto, per
+// simulare un guasto di lettura reale a metà di un frame.
+type failingReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.pos >= len(r.data) {
+		return 0, r.err
+	}
+	n := copy(p, r.data[r.pos:])
+	r.pos += n
+	return n, nil
+}
+
+func TestNextFramePropagatesReadErrors(t *testing.T) {
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, newHeader())
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	if err := enc.WriteFrame(Frame{Size: 1, Content: []byte("a")}); err != nil {
+		t.Fatalf("WriteFrame: %v", err)
+	}
+
+	// Tronca subito dopo il byte di tipo-frame: un errore di lettura reale
+	// a metà frame, non una fine pulita dello stream.
+	truncated := buf.Bytes()[:binary.Size(Header{})+1]
+	wantErr := errors.New("boom")
+	r := &failingReader{data: truncated, err: wantErr}
+
+	dec, err := NewDecoder(r)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	if _, err := dec.NextFrame(); !errors.Is(err, wantErr) {
+		t.Fatalf("NextFrame error = %v, want %v (a real read error must not be swallowed as io.EOF)", err, wantErr)
+	}
+}
+
+// TestReadASCVDetectsTruncatedFrame riproduce il bug di troncamento: un file
+// con header.Frames=2 tagliato a metà del secondo frame deve far fallire
+// ReadASCV/VerifyASCV, non restituire silenziosamente solo il primo frame
+// come se lo stream fosse finito pulito.
+func TestReadASCVDetectsTruncatedFrame(t *testing.T) {
+	header := newHeader()
+	frames := []Frame{
+		{Size: 1, Content: []byte("a")},
+		{Size: 1, Content: []byte("b")},
+	}
+
+	path := filepath.Join(t.TempDir(), "anim.ascv")
+	if err := WriteASCV(path, header, frames); err != nil {
+		t.Fatalf("WriteASCV: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	// Ogni frame di un singolo byte occupa 5 byte su disco (tipo, size VLQ,
+	// preambolo codec/charset, contenuto). Tronca subito dopo il tag di
+	// tipo del secondo frame, ben prima della fine dichiarata da
+	// header.Frames.
+	truncated := data[:binary.Size(Header{})+5+1]
+	if err := os.WriteFile(path, truncated, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	gotHeader, gotFrames, err := ReadASCV(path)
+	if err == nil {
+		t.Fatalf("ReadASCV on truncated file = (%+v, %v, nil), want an error", gotHeader, gotFrames)
+	}
+	if errors.Is(err, io.EOF) {
+		t.Fatalf("ReadASCV on truncated file = %v, want a non-EOF error (truncation must not look like a clean end)", err)
+	}
+
+	if err := VerifyASCV(path); err == nil || errors.Is(err, io.EOF) {
+		t.Fatalf("VerifyASCV on truncated file = %v, want a non-EOF error", err)
+	}
+}
+
+// TestDecodeRawFrameRejectsOversizedFrame verifica che un preambolo con una
+// size VLQ abnorme venga rifiutato prima di allocare un buffer di quella
+// dimensione, a protezione dello scenario di stream di rete motivato da
+// NewEncoder/NewDecoder.
+func TestDecodeRawFrameRejectsOversizedFrame(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteByte(FrameTypeKey)
+	buf.Write(encodeVLQ(maxFrameSize + 1))
+	buf.Write([]byte{CompressionNone, CharsetASCII})
+
+	_, err := decodeRawFrame(&buf, ChecksumNone, false)
+	if err == nil {
+		t.Fatal("decodeRawFrame: want error for oversized frame size, got nil")
+	}
+}
+
+func TestWriteASCVReadASCVRoundTrip(t *testing.T) {
+	header := newHeader()
+	header.Width, header.Height = 3, 1
+	frames := []Frame{
+		{Size: 3, Content: []byte("xyz")},
+		{Size: 3, Content: []byte("zyx")},
+	}
+
+	path := filepath.Join(t.TempDir(), "anim.ascv")
+	if err := WriteASCV(path, header, frames); err != nil {
+		t.Fatalf("WriteASCV: %v", err)
+	}
+
+	gotHeader, gotFrames, err := ReadASCV(path)
+	if err != nil {
+		t.Fatalf("ReadASCV: %v", err)
+	}
+	if gotHeader.Width != header.Width || gotHeader.Height != header.Height {
+		t.Errorf("header = %+v, want width/height %d/%d", gotHeader, header.Width, header.Height)
+	}
+	if len(gotFrames) != len(frames) {
+		t.Fatalf("got %d frames, want %d", len(gotFrames), len(frames))
+	}
+	for i, f := range gotFrames {
+		if !bytes.Equal(f.Content, frames[i].Content) {
+			t.Errorf("frame %d content = %q, want %q", i, f.Content, frames[i].Content)
+		}
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+}